@@ -0,0 +1,47 @@
+package ecsevent
+
+import "context"
+
+// monitorContextKey is the context key under which a Monitor is stored by
+// NewContext. It's unexported so callers can't collide with it from
+// outside the package.
+type monitorContextKey struct{}
+
+// noopMonitor is the Monitor returned by FromContext when no Monitor has
+// been attached to the context, so callers can record against the result
+// of FromContext unconditionally rather than nil-checking it.
+type noopMonitor struct{}
+
+func (noopMonitor) Record(map[string]interface{})       {}
+func (noopMonitor) Fields() map[string]interface{}      { return nil }
+func (noopMonitor) UpdateFields(map[string]interface{}) {}
+func (noopMonitor) Suppress()                           {}
+
+var _ Monitor = noopMonitor{}
+
+// NewContext returns a copy of ctx carrying m, retrievable with FromContext.
+func NewContext(ctx context.Context, m Monitor) context.Context {
+	return context.WithValue(ctx, monitorContextKey{}, m)
+}
+
+// FromContext returns the Monitor attached to ctx by NewContext, or a
+// no-op Monitor if none was attached.
+func FromContext(ctx context.Context) Monitor {
+	if m, ok := ctx.Value(monitorContextKey{}).(Monitor); ok && m != nil {
+		return m
+	}
+	return noopMonitor{}
+}
+
+// StartSpan creates a new SpanMonitor as a child of whatever Monitor is
+// attached to ctx (or a no-op Monitor, if none is), seeds it with an
+// event.action field set to name, and returns a context carrying the new
+// SpanMonitor alongside the SpanMonitor itself. Callers are responsible
+// for calling Finish on the returned SpanMonitor, typically via defer.
+func StartSpan(ctx context.Context, name string, opts ...SpanMonitorOption) (context.Context, *SpanMonitor) {
+	withAction := func(sm *SpanMonitor) {
+		sm.fields[FieldEventAction] = name
+	}
+	span := NewSpanMonitorFromParent(FromContext(ctx), append([]SpanMonitorOption{withAction}, opts...)...)
+	return NewContext(ctx, span), span
+}
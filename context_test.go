@@ -0,0 +1,36 @@
+package ecsevent
+
+import (
+	"context"
+	"testing"
+)
+
+// seenFieldsSampler records the fields it was consulted with, so tests can
+// check what a SpanMonitor has seeded by the time sampling happens.
+type seenFieldsSampler struct {
+	seen map[string]interface{}
+}
+
+func (s *seenFieldsSampler) ShouldSample(_ bool, fields map[string]interface{}) SamplingDecision {
+	s.seen = fields
+	return SamplingDecision{Sampled: true}
+}
+
+func TestStartSpanSeedsActionBeforeSampling(t *testing.T) {
+	sampler := &seenFieldsSampler{}
+	root := NewRootMonitor(func(map[string]interface{}) {})
+	root.SetSampler(sampler)
+
+	ctx, span := StartSpan(NewContext(context.Background(), root), "do-the-thing")
+	defer span.Finish()
+	_ = ctx
+
+	if action, _ := sampler.seen[FieldEventAction].(string); action != "do-the-thing" {
+		t.Fatalf("sampler saw event.action = %q, want %q to have been seeded before sampling", action, "do-the-thing")
+	}
+}
+
+func TestFromContextReturnsNoopWhenUnset(t *testing.T) {
+	m := FromContext(context.Background())
+	m.Record(map[string]interface{}{"n": 1}) // must not panic
+}
@@ -0,0 +1,121 @@
+package ecsevent
+
+import (
+	"net/http"
+	"strconv"
+)
+
+const (
+	// HeaderTraceID carries the OpenTelemetry trace ID of the SpanMonitor's
+	// span, if any, or else its FieldTraceID field.
+	HeaderTraceID = "Ecsevent-Trace-Id"
+
+	// HeaderSpanID carries the OpenTelemetry span ID of the SpanMonitor's
+	// span, if any, or else its FieldParentSpanID field.
+	HeaderSpanID = "Ecsevent-Span-Id"
+
+	// HeaderSampled carries the trace's sampling decision.
+	HeaderSampled = "Ecsevent-Sampled"
+
+	// HeaderSubeventsField carries the SubeventsField name in use, so a
+	// downstream service emits its own subevents under the same field.
+	HeaderSubeventsField = "Ecsevent-Subevents-Field"
+)
+
+// FieldTraceID is the field under which a trace ID recovered from Extract
+// is recorded on a SpanMonitor continuing that trace.
+const FieldTraceID = "trace.id"
+
+// FieldParentSpanID is the field under which an upstream span ID recovered
+// from Extract is recorded on a SpanMonitor continuing that trace.
+const FieldParentSpanID = "span.parent_id"
+
+// Inject serializes sm's correlation fields into headers so that a
+// downstream service can continue the same logical trace after calling
+// Extract and passing the result to NewSpanMonitorFromParent or StartSpan
+// via WithCorrelation.
+func Inject(sm *SpanMonitor, headers http.Header) {
+	if sm == nil {
+		return
+	}
+	sm.mu.RLock()
+	defer sm.mu.RUnlock()
+
+	traceID, spanID := sm.fields[FieldTraceID], sm.fields[FieldParentSpanID]
+	if sm.otelSpan != nil {
+		sc := sm.otelSpan.SpanContext()
+		if sc.HasTraceID() {
+			traceID = sc.TraceID().String()
+		}
+		if sc.HasSpanID() {
+			spanID = sc.SpanID().String()
+		}
+	}
+	if s, ok := traceID.(string); ok && s != "" {
+		headers.Set(HeaderTraceID, s)
+	}
+	if s, ok := spanID.(string); ok && s != "" {
+		headers.Set(HeaderSpanID, s)
+	}
+	headers.Set(HeaderSampled, strconv.FormatBool(sm.sampled))
+	if sm.SubeventsField != "" {
+		headers.Set(HeaderSubeventsField, sm.SubeventsField)
+	}
+}
+
+// Correlation holds the correlation fields recovered from an inbound
+// request by Extract.
+type Correlation struct {
+	TraceID string
+	SpanID  string
+
+	// Sampled is the upstream sampling decision. Only meaningful when
+	// SampledPresent is true; an inbound request with no HeaderSampled
+	// header carries no sampling decision to propagate at all.
+	Sampled bool
+
+	// SampledPresent reports whether HeaderSampled was actually present on
+	// the inbound request. WithCorrelation uses this to tell "upstream
+	// decided not to sample" from "upstream never made a decision", so it
+	// only overrides the root Sampler in the former case.
+	SampledPresent bool
+
+	SubeventsField string
+}
+
+// Extract recovers the correlation fields previously written by Inject.
+func Extract(headers http.Header) Correlation {
+	sampledHeader := headers.Get(HeaderSampled)
+	sampled, _ := strconv.ParseBool(sampledHeader)
+	return Correlation{
+		TraceID:        headers.Get(HeaderTraceID),
+		SpanID:         headers.Get(HeaderSpanID),
+		Sampled:        sampled,
+		SampledPresent: sampledHeader != "",
+		SubeventsField: headers.Get(HeaderSubeventsField),
+	}
+}
+
+// WithCorrelation applies a Correlation recovered by Extract to a new
+// SpanMonitor, so a trace continued from an upstream service keeps that
+// service's sampling decision, SubeventsField, and correlation IDs instead
+// of starting a new trace from scratch. If the inbound request carried no
+// sampling decision at all (SampledPresent is false), sampling is left to
+// the root monitor's Sampler rather than being forced to false.
+func WithCorrelation(corr Correlation) SpanMonitorOption {
+	return func(sm *SpanMonitor) {
+		if corr.SampledPresent {
+			sm.sampled = corr.Sampled
+			sm.sampledHintSet = true
+		}
+		if corr.SubeventsField != "" {
+			sm.SubeventsField = corr.SubeventsField
+		}
+		if corr.TraceID != "" {
+			sm.fields[FieldTraceID] = corr.TraceID
+		}
+		if corr.SpanID != "" {
+			sm.fields[FieldParentSpanID] = corr.SpanID
+		}
+	}
+}
@@ -0,0 +1,82 @@
+package ecsevent
+
+import (
+	"net/http"
+	"testing"
+)
+
+func TestInjectExtractRoundTripsSampledAndSubeventsField(t *testing.T) {
+	parent := &recordingMonitor{}
+	sm := NewSpanMonitorFromParent(parent, WithSampledHint(true))
+	sm.SubeventsField = "custom.subevents"
+
+	headers := make(http.Header)
+	Inject(sm, headers)
+
+	corr := Extract(headers)
+	if !corr.SampledPresent {
+		t.Fatal("Extract().SampledPresent = false, want true (HeaderSampled was set by Inject)")
+	}
+	if !corr.Sampled {
+		t.Fatal("Extract().Sampled = false, want true")
+	}
+	if corr.SubeventsField != "custom.subevents" {
+		t.Fatalf("Extract().SubeventsField = %q, want %q", corr.SubeventsField, "custom.subevents")
+	}
+}
+
+func TestInjectFallsBackToFieldsWhenNoOtelSpan(t *testing.T) {
+	parent := &recordingMonitor{}
+	sm := NewSpanMonitorFromParent(parent, WithCorrelation(Correlation{
+		TraceID: "trace-abc",
+		SpanID:  "span-123",
+	}))
+
+	headers := make(http.Header)
+	Inject(sm, headers)
+
+	if got := headers.Get(HeaderTraceID); got != "trace-abc" {
+		t.Fatalf("HeaderTraceID = %q, want %q (from sm.fields, no otelSpan set)", got, "trace-abc")
+	}
+	if got := headers.Get(HeaderSpanID); got != "span-123" {
+		t.Fatalf("HeaderSpanID = %q, want %q (from sm.fields, no otelSpan set)", got, "span-123")
+	}
+}
+
+func TestWithCorrelationAppliesTraceAndSpanIDs(t *testing.T) {
+	parent := &recordingMonitor{}
+	corr := Correlation{
+		TraceID:        "trace-abc",
+		SpanID:         "span-123",
+		Sampled:        false,
+		SampledPresent: true,
+		SubeventsField: "inherited.subevents",
+	}
+	sm := NewSpanMonitorFromParent(parent, WithCorrelation(corr))
+
+	if sm.sampled {
+		t.Fatal("sampled = true, want false (inherited from Correlation)")
+	}
+	if sm.SubeventsField != "inherited.subevents" {
+		t.Fatalf("SubeventsField = %q, want %q", sm.SubeventsField, "inherited.subevents")
+	}
+	if got := sm.fields[FieldTraceID]; got != "trace-abc" {
+		t.Fatalf("fields[FieldTraceID] = %v, want %q", got, "trace-abc")
+	}
+	if got := sm.fields[FieldParentSpanID]; got != "span-123" {
+		t.Fatalf("fields[FieldParentSpanID] = %v, want %q", got, "span-123")
+	}
+}
+
+func TestWithCorrelationLeavesSamplingToRootWhenAbsent(t *testing.T) {
+	root := NewRootMonitor(func(map[string]interface{}) {})
+	root.SetSampler(NeverSample)
+
+	sm := NewSpanMonitorFromParent(root, WithCorrelation(Correlation{}))
+	if sm.sampled {
+		t.Fatal("sampled = true, want false (root Sampler is NeverSample and Correlation carried no decision)")
+	}
+	if sm.sampledHintSet {
+		t.Fatal("sampledHintSet = true, want false: an empty Correlation shouldn't force a sampling decision")
+	}
+}
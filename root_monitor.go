@@ -0,0 +1,96 @@
+package ecsevent
+
+import "sync"
+
+// RootMonitor is the top of a Monitor tree. It has no parent of its own and
+// is ultimately responsible for emitting every event recorded against it or
+// any descendant SpanMonitor.
+type RootMonitor struct {
+	// Emit is called with the fully merged fields for every event recorded
+	// against this monitor or any of its descendants. If nil, Record is a
+	// no-op.
+	Emit func(fields map[string]interface{})
+
+	// sampler decides whether new traces rooted here should be sampled.
+	// Defaults to AlwaysSample; set it with SetSampler.
+	sampler Sampler
+
+	fields map[string]interface{}
+	mu     sync.RWMutex
+}
+
+var (
+	// This is a compile-time check to make sure our types correctly
+	// implement the interface:
+	// https://medium.com/@matryer/c167afed3aae
+	_ Monitor = &RootMonitor{}
+)
+
+// NewRootMonitor creates a RootMonitor that hands every recorded event to
+// emit.
+func NewRootMonitor(emit func(fields map[string]interface{})) *RootMonitor {
+	return &RootMonitor{
+		Emit:   emit,
+		fields: make(map[string]interface{}),
+	}
+}
+
+// Fields returns the RootMonitor's locally scoped fields.
+func (rm *RootMonitor) Fields() map[string]interface{} {
+	rm.mu.RLock()
+	defer rm.mu.RUnlock()
+	return rm.fields
+}
+
+// UpdateFields updates the RootMonitor's field set.
+func (rm *RootMonitor) UpdateFields(fields map[string]interface{}) {
+	rm.mu.Lock()
+	defer rm.mu.Unlock()
+	if rm.fields == nil {
+		rm.fields = make(map[string]interface{})
+	}
+	for k, v := range fields {
+		rm.fields[k] = v
+	}
+}
+
+// Suppress is a no-op on RootMonitor: there's no further parent to suppress
+// emission to.
+func (rm *RootMonitor) Suppress() {}
+
+// Record merges event with the RootMonitor's own fields and hands the
+// result to Emit.
+func (rm *RootMonitor) Record(event map[string]interface{}) {
+	rm.mu.RLock()
+	defer rm.mu.RUnlock()
+	if rm.Emit == nil {
+		return
+	}
+	merged := make(map[string]interface{}, len(rm.fields)+len(event))
+	for k, v := range rm.fields {
+		merged[k] = v
+	}
+	for k, v := range event {
+		merged[k] = v
+	}
+	rm.Emit(merged)
+}
+
+// Sampler returns the Sampler used to make sampling decisions for new
+// traces rooted at rm. Defaults to AlwaysSample; never nil.
+func (rm *RootMonitor) Sampler() Sampler {
+	rm.mu.RLock()
+	defer rm.mu.RUnlock()
+	if rm.sampler == nil {
+		return AlwaysSample
+	}
+	return rm.sampler
+}
+
+// SetSampler configures the Sampler used to make sampling decisions for new
+// traces rooted at rm.
+func (rm *RootMonitor) SetSampler(sampler Sampler) {
+	rm.mu.Lock()
+	defer rm.mu.Unlock()
+	rm.sampler = sampler
+}
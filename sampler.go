@@ -0,0 +1,100 @@
+package ecsevent
+
+import (
+	"math/rand"
+	"sync"
+	"time"
+)
+
+// FieldTraceSampled is the field under which a trace's sampling decision is
+// recorded on the event emitted by a sampled or unsampled SpanMonitor.
+const FieldTraceSampled = "trace.sampled"
+
+// SamplingDecision is the result of consulting a Sampler.
+type SamplingDecision struct {
+	// Sampled indicates whether the trace should be recorded.
+	Sampled bool
+}
+
+// Sampler decides whether a trace rooted at a RootMonitor should be
+// sampled. It's consulted by NewSpanMonitorFromParent whenever a new
+// SpanMonitor is created without an already-sampled SpanMonitor parent.
+type Sampler interface {
+	// ShouldSample makes a sampling decision for a new trace. parentSampled
+	// reflects whether an upstream caller already made a sampling decision
+	// (e.g. via a propagated trace header); fields are the fields recorded
+	// on the new SpanMonitor so far.
+	ShouldSample(parentSampled bool, fields map[string]interface{}) SamplingDecision
+}
+
+// AlwaysSample is a Sampler that samples every trace.
+var AlwaysSample Sampler = alwaysSample{}
+
+type alwaysSample struct{}
+
+func (alwaysSample) ShouldSample(bool, map[string]interface{}) SamplingDecision {
+	return SamplingDecision{Sampled: true}
+}
+
+// NeverSample is a Sampler that never samples a trace.
+var NeverSample Sampler = neverSample{}
+
+type neverSample struct{}
+
+func (neverSample) ShouldSample(bool, map[string]interface{}) SamplingDecision {
+	return SamplingDecision{Sampled: false}
+}
+
+// ProbabilitySampler returns a Sampler that samples a trace with
+// probability p, independent of any upstream sampling decision. p is
+// clamped to [0, 1].
+func ProbabilitySampler(p float64) Sampler {
+	if p < 0 {
+		p = 0
+	} else if p > 1 {
+		p = 1
+	}
+	return probabilitySampler(p)
+}
+
+type probabilitySampler float64
+
+func (p probabilitySampler) ShouldSample(_ bool, _ map[string]interface{}) SamplingDecision {
+	return SamplingDecision{Sampled: rand.Float64() < float64(p)}
+}
+
+// RateLimitingSampler returns a Sampler that samples at most perSecond
+// traces per second using a token bucket, smoothing out bursts the way a
+// fixed-probability sampler can't.
+func RateLimitingSampler(perSecond float64) Sampler {
+	return &rateLimitingSampler{
+		perSecond:  perSecond,
+		tokens:     perSecond,
+		lastRefill: time.Now(),
+	}
+}
+
+type rateLimitingSampler struct {
+	mu         sync.Mutex
+	perSecond  float64
+	tokens     float64
+	lastRefill time.Time
+}
+
+func (s *rateLimitingSampler) ShouldSample(_ bool, _ map[string]interface{}) SamplingDecision {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := time.Now()
+	s.tokens += now.Sub(s.lastRefill).Seconds() * s.perSecond
+	if s.tokens > s.perSecond {
+		s.tokens = s.perSecond
+	}
+	s.lastRefill = now
+
+	if s.tokens < 1 {
+		return SamplingDecision{Sampled: false}
+	}
+	s.tokens--
+	return SamplingDecision{Sampled: true}
+}
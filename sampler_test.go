@@ -0,0 +1,40 @@
+package ecsevent
+
+import "testing"
+
+func TestAlwaysSample(t *testing.T) {
+	if !AlwaysSample.ShouldSample(false, nil).Sampled {
+		t.Fatal("AlwaysSample.ShouldSample() = false, want true")
+	}
+}
+
+func TestNeverSample(t *testing.T) {
+	if NeverSample.ShouldSample(true, nil).Sampled {
+		t.Fatal("NeverSample.ShouldSample() = true, want false")
+	}
+}
+
+func TestProbabilitySamplerClampsToUnitInterval(t *testing.T) {
+	if !ProbabilitySampler(2).ShouldSample(false, nil).Sampled {
+		// p is clamped to 1, so it should always sample.
+		t.Fatal("ProbabilitySampler(2).ShouldSample() = false, want true")
+	}
+	if ProbabilitySampler(-1).ShouldSample(false, nil).Sampled {
+		// p is clamped to 0, so it should never sample.
+		t.Fatal("ProbabilitySampler(-1).ShouldSample() = true, want false")
+	}
+}
+
+func TestRateLimitingSamplerCapsBurstAtPerSecond(t *testing.T) {
+	sampler := RateLimitingSampler(2)
+
+	sampled := 0
+	for i := 0; i < 5; i++ {
+		if sampler.ShouldSample(false, nil).Sampled {
+			sampled++
+		}
+	}
+	if sampled != 2 {
+		t.Fatalf("got %d sampled out of 5 immediate calls, want 2 (the token bucket's starting capacity)", sampled)
+	}
+}
@@ -1,11 +1,26 @@
 package ecsevent
 
 import (
+	"errors"
 	"sync"
+	"time"
 
 	"github.com/opentracing/opentracing-go"
+	"github.com/opentracing/opentracing-go/ext"
+	"github.com/opentracing/opentracing-go/log"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
 )
 
+// FieldEventSubeventsDropped is the field under which the count of
+// subevents evicted due to WithMaxBufferedSubevents is recorded.
+const FieldEventSubeventsDropped = "event.subevents_dropped"
+
+// FieldEventSubeventsCount is the field under which the total number of
+// recorded subevents is reported in the FlushImmediate summary event.
+const FieldEventSubeventsCount = "event.subevents_count"
+
 // SpanMonitor is a short-lived monitor with additional contextual fields.
 // It's typically used in conjunction with a Context. It relies on a parent
 // Monitor to emit.
@@ -16,20 +31,101 @@ type SpanMonitor struct {
 	// span.
 	SubeventsField string
 
-	// subevents tracks all events recorded against this span monitor.
+	// subevents tracks all events recorded against this span monitor. It's
+	// backed by subeventsArr until more than len(subeventsArr) are recorded,
+	// at which point append promotes it to a heap-allocated slice.
 	subevents []map[string]interface{}
 
+	// subeventsArr is inline storage for the common case of a handful of
+	// subevents per span, avoiding a heap allocation for subevents on the
+	// hot path.
+	subeventsArr [4]map[string]interface{}
+
 	// Fields are the locally scoped fields applied to all events and subevents
 	// recorded by the SpanMonitor.
 	fields map[string]interface{}
 
 	// The opentracing span, if any, associated with this SpanMonitor.
-	span       opentracing.Span
+	span opentracing.Span
+
+	// ownsSpan is true when span was given to this SpanMonitor directly via
+	// WithOpenTracingSpan, as opposed to inherited from a parent
+	// SpanMonitor. Only the owner may finish the span.
+	ownsSpan bool
+
+	// The OpenTelemetry span, if any, associated with this SpanMonitor. This
+	// may be set alongside span so that a SpanMonitor can feed both backends
+	// during the OpenTracing-to-OpenTelemetry migration.
+	otelSpan trace.Span
+
+	// ownsOtelSpan is true when otelSpan was given to this SpanMonitor
+	// directly via WithOpenTelemetrySpan, as opposed to inherited from a
+	// parent SpanMonitor.
+	ownsOtelSpan bool
+
+	// flushMode controls when subevents are emitted to parent. Defaults to
+	// FlushOnFinish.
+	flushMode FlushMode
+
+	// maxBufferedSubevents bounds the number of subevents held in memory at
+	// once. Zero means unbounded. Has no effect in FlushImmediate mode, since
+	// subevents are never buffered in the first place.
+	maxBufferedSubevents int
+
+	// subeventsDropped counts subevents evicted because maxBufferedSubevents
+	// was exceeded.
+	subeventsDropped int
+
+	// recordCount is the total number of events Record has accepted,
+	// including subevents that were flushed immediately or dropped. Calls
+	// made while suppressed or unsampled are not counted.
+	recordCount int
+
+	// startedAt is used to compute the duration reported in the summary event
+	// emitted by Finish in FlushImmediate mode.
+	startedAt time.Time
+
+	// sampled records the sampling decision made for this SpanMonitor's
+	// trace, either inherited from a sampled parent or obtained from the
+	// root monitor's Sampler.
+	sampled bool
+
+	// sampledHintSet is true when WithSampledHint forced the sampling
+	// decision, overriding inheritance from the parent or the root Sampler.
+	sampledHintSet bool
+
 	parent     Monitor
 	suppressed bool
 	mu         *sync.RWMutex
 }
 
+// FlushMode controls when a SpanMonitor emits its recorded subevents to its
+// parent Monitor.
+type FlushMode struct {
+	every int
+}
+
+// FlushOnFinish buffers all subevents in memory and emits them as a single
+// aggregate event when Finish is called. This is the default and matches
+// the SpanMonitor's historical behavior.
+var FlushOnFinish = FlushMode{every: 0}
+
+// FlushImmediate emits each recorded subevent to the parent Monitor as soon
+// as Record is called, rather than buffering it. Finish then only emits a
+// summary event. Use this for long-lived spans where buffering every
+// subevent would grow unbounded.
+var FlushImmediate = FlushMode{every: 1}
+
+// FlushEvery returns a FlushMode that buffers subevents and flushes them to
+// the parent Monitor as a batch once n have accumulated. n must be at least
+// 2; smaller values are treated as FlushImmediate.
+func FlushEvery(n int) FlushMode {
+	if n < 2 {
+		return FlushImmediate
+	}
+	return FlushMode{every: n}
+}
+
 var (
 	// This is a compile-time check to make sure our types correctly
 	// implement the interface:
@@ -44,22 +140,97 @@ type SpanMonitorOption func(*SpanMonitor)
 func WithOpenTracingSpan(span opentracing.Span) SpanMonitorOption {
 	return func(sm *SpanMonitor) {
 		sm.span = span
+		sm.ownsSpan = true
+	}
+}
+
+// WithOpenTelemetrySpan associates an OpenTelemetry span with the span
+// monitor. It may be used alongside WithOpenTracingSpan; both backends will
+// receive the subevents still buffered on Finish. Subevents already handed to
+// the parent by FlushImmediate or FlushEvery are not sent to either span.
+func WithOpenTelemetrySpan(span trace.Span) SpanMonitorOption {
+	return func(sm *SpanMonitor) {
+		sm.otelSpan = span
+		sm.ownsOtelSpan = true
+	}
+}
+
+// WithFlushMode controls when the span monitor emits its recorded subevents
+// to its parent Monitor. Defaults to FlushOnFinish.
+func WithFlushMode(mode FlushMode) SpanMonitorOption {
+	return func(sm *SpanMonitor) {
+		sm.flushMode = mode
+	}
+}
+
+// WithMaxBufferedSubevents bounds the number of subevents a span monitor
+// holds in memory at once, dropping the oldest ones once the limit is
+// exceeded and tracking the drop count in FieldEventSubeventsDropped. Has no
+// effect in FlushImmediate mode, since subevents aren't buffered there.
+func WithMaxBufferedSubevents(n int) SpanMonitorOption {
+	return func(sm *SpanMonitor) {
+		sm.maxBufferedSubevents = n
+	}
+}
+
+// WithSampledHint forces a SpanMonitor's sampling decision rather than
+// deferring to the parent SpanMonitor or the root monitor's Sampler. It's
+// most useful when continuing a trace whose sampling decision was
+// propagated from an upstream service, e.g. via Extract.
+func WithSampledHint(sampled bool) SpanMonitorOption {
+	return func(sm *SpanMonitor) {
+		sm.sampled = sampled
+		sm.sampledHintSet = true
 	}
 }
 
+// spanMonitorPool reuses SpanMonitors across the lifetime of a RootMonitor
+// to cut allocations on hot tracing paths. Monitors are returned to the
+// pool by calling Release; see its doc for why Finish doesn't do this
+// automatically.
+var spanMonitorPool = sync.Pool{
+	New: func() interface{} {
+		return &SpanMonitor{mu: &sync.RWMutex{}}
+	},
+}
+
 // NewSpanMonitorFromParent creates a new
 func NewSpanMonitorFromParent(m Monitor, opts ...SpanMonitorOption) *SpanMonitor {
-	monitor := &SpanMonitor{
-		mu:             &sync.RWMutex{},
-		parent:         m,
-		fields:         make(map[string]interface{}),
-		subevents:      make([]map[string]interface{}, 0),
-		SubeventsField: FieldEventSubevents,
+	monitor := spanMonitorPool.Get().(*SpanMonitor)
+	monitor.parent = m
+	monitor.SubeventsField = FieldEventSubevents
+	monitor.startedAt = time.Now()
+	if monitor.fields == nil {
+		monitor.fields = make(map[string]interface{})
 	}
+	monitor.subevents = monitor.subeventsArr[:0]
 	for _, opts := range opts {
 		opts(monitor)
 	}
-	// TODO: if m is also a SpanMonitor, connect the opentracing spans together if non-nil
+	// If the parent is itself a SpanMonitor and this monitor wasn't given a
+	// span of its own, inherit the parent's so that nested SpanMonitors
+	// without their own instrumentation still contribute their subevents to
+	// the enclosing span.
+	if parent, ok := m.(*SpanMonitor); ok && parent != nil {
+		if monitor.span == nil {
+			monitor.span = parent.span
+		}
+		if monitor.otelSpan == nil {
+			monitor.otelSpan = parent.otelSpan
+		}
+		// A parent SpanMonitor has already had its sampling decision made;
+		// inherit it rather than sampling this trace twice.
+		if !monitor.sampledHintSet {
+			monitor.sampled = parent.sampled
+		}
+	} else if monitor.sampledHintSet {
+		// WithSampledHint already decided for us, e.g. because the trace
+		// was continued from a propagated sampling decision via Extract.
+	} else if root := monitor.Root(); root != nil {
+		monitor.sampled = root.Sampler().ShouldSample(false, monitor.fields).Sampled
+	} else {
+		monitor.sampled = true
+	}
 	return monitor
 }
 
@@ -119,43 +290,298 @@ func (sm *SpanMonitor) Suppress() {
 
 // Record takes a series of fields and records an event.
 func (sm *SpanMonitor) Record(event map[string]interface{}) {
-	if sm.fields == nil {
-		sm.mu.Lock()
-		sm.fields = make(map[string]interface{})
-		sm.mu.Unlock()
-	}
-	merged := make(map[string]interface{})
 	sm.mu.RLock()
-	for k, v := range sm.fields {
-		merged[k] = v
+	skip := sm.suppressed || !sm.sampled
+	sm.mu.RUnlock()
+	if skip {
+		// Covers both explicit Suppress() and an unsampled trace. Bailing
+		// out here, before any field-copying or merging, keeps the cost of
+		// a disabled trace negligible.
+		return
 	}
+
+	sm.mu.RLock()
+	hasLocalFields := len(sm.fields) > 0
 	sm.mu.RUnlock()
-	for k, v := range event {
-		merged[k] = v
+
+	var merged map[string]interface{}
+	if !hasLocalFields {
+		// No locally scoped fields to merge in, so the event map can be used
+		// as-is without allocating a copy.
+		merged = event
+	} else {
+		merged = make(map[string]interface{}, len(sm.fields)+len(event))
+		sm.mu.RLock()
+		for k, v := range sm.fields {
+			merged[k] = v
+		}
+		sm.mu.RUnlock()
+		for k, v := range event {
+			merged[k] = v
+		}
 	}
+
 	sm.mu.Lock()
-	sm.subevents = append(sm.subevents, merged)
-	defer sm.mu.Unlock()
-	if sm.suppressed {
+	if sm.suppressed || !sm.sampled {
+		sm.mu.Unlock()
+		return
+	}
+	sm.recordCount++
+
+	if sm.flushMode.every == 1 {
+		// FlushImmediate: hand the merged event straight to the parent
+		// instead of buffering it, so long-lived spans don't accumulate
+		// subevents in memory.
+		sm.mu.Unlock()
+		sm.parent.Record(merged)
 		return
 	}
-	// TODO: if configured to flush immediately, emit to parent, otherwise emit on Finish
+
+	sm.subevents = append(sm.subevents, merged)
+	if sm.maxBufferedSubevents > 0 && len(sm.subevents) > sm.maxBufferedSubevents {
+		overflow := len(sm.subevents) - sm.maxBufferedSubevents
+		sm.subeventsDropped += overflow
+		sm.subevents = sm.subevents[overflow:]
+	}
+
+	var batch []map[string]interface{}
+	if sm.flushMode.every > 1 && len(sm.subevents) >= sm.flushMode.every {
+		batch = sm.subevents
+		sm.subevents = make([]map[string]interface{}, 0, sm.flushMode.every)
+	}
+	sm.mu.Unlock()
+
+	if batch != nil {
+		sm.parent.Record(map[string]interface{}{sm.SubeventsField: batch})
+	}
 }
 
 func (sm *SpanMonitor) Finish() {
 	sm.mu.Lock()
-	defer sm.mu.Unlock()
 	if sm.suppressed {
+		sm.mu.Unlock()
+		return
+	}
+	if !sm.sampled {
+		// Sampled out: skip the span/otel work and subevent bookkeeping
+		// below entirely, but still emit a minimal event carrying the
+		// sampling decision so downstream processors can distinguish
+		// sampled-in from sampled-out traces rather than seeing nothing.
+		sm.fields[FieldTraceSampled] = false
+		parent := sm.parent
+		emitFields := sm.fields
+		sm.mu.Unlock()
+		parent.Record(emitFields)
+		return
+	}
+	if sm.recordCount == 0 && len(sm.fields) == 0 {
+		// This span monitor never recorded anything and has no local fields
+		// of its own, so there's nothing worth materializing into an event.
+		// Gated on this monitor's own state only, so records made elsewhere
+		// in the trace don't cause an empty event to be emitted for this
+		// span.
+		sm.mu.Unlock()
 		return
 	}
 	if sm.span != nil {
-		records := make([]opentracing.LogRecord, 0)
-		// TODO: generate log records for each subevent
-		opts := opentracing.FinishOptions{LogRecords: records}
-		sm.span.FinishWithOptions(opts)
+		for _, subevent := range sm.subevents {
+			applySpanTags(sm.span, subevent)
+		}
+		if sm.ownsSpan {
+			// Only the monitor that was actually given this span (as
+			// opposed to one that inherited it from a parent SpanMonitor)
+			// may finish it; opentracing spans are only meant to be
+			// finished once.
+			records := make([]opentracing.LogRecord, 0, len(sm.subevents))
+			for _, subevent := range sm.subevents {
+				records = append(records, opentracing.LogRecord{
+					Timestamp: subeventTimestamp(subevent),
+					Fields:    subeventLogFields(subevent),
+				})
+			}
+			sm.span.FinishWithOptions(opentracing.FinishOptions{LogRecords: records})
+		} else {
+			for _, subevent := range sm.subevents {
+				sm.span.LogFields(subeventLogFields(subevent)...)
+			}
+		}
+	}
+	if sm.otelSpan != nil {
+		for _, subevent := range sm.subevents {
+			sm.otelSpan.AddEvent(
+				subeventName(subevent),
+				trace.WithAttributes(subeventAttributes(subevent)...),
+				trace.WithTimestamp(subeventTimestamp(subevent)),
+			)
+			if msg, ok := subevent[FieldErrorMessage].(string); ok && msg != "" {
+				sm.otelSpan.RecordError(errors.New(msg))
+				sm.otelSpan.SetStatus(codes.Error, msg)
+			}
+		}
+		if sm.ownsOtelSpan {
+			// Only the monitor that was actually given this span (as
+			// opposed to one that inherited it from a parent SpanMonitor)
+			// may end it; OpenTelemetry spans are only meant to be ended
+			// once.
+			sm.otelSpan.End()
+		}
 	}
-	if len(sm.subevents) > 0 {
+	if sm.subeventsDropped > 0 {
+		sm.fields[FieldEventSubeventsDropped] = sm.subeventsDropped
+	}
+	sm.fields[FieldTraceSampled] = true
+
+	if sm.flushMode.every == 1 {
+		// Subevents were already emitted to the parent as they were
+		// recorded; emitting them again here would duplicate data, so
+		// Finish only contributes a summary of what this span monitor saw.
+		sm.fields[FieldEventDuration] = time.Since(sm.startedAt)
+		sm.fields[FieldEventSubeventsCount] = sm.recordCount
+	} else if len(sm.subevents) > 0 {
 		sm.fields[sm.SubeventsField] = sm.subevents
 	}
-	sm.parent.Record(sm.fields)
+
+	parent := sm.parent
+	emitFields := sm.fields
+	sm.mu.Unlock()
+
+	parent.Record(emitFields)
+}
+
+// Release clears this SpanMonitor's state and returns it to the shared
+// pool for reuse by NewSpanMonitorFromParent. Finish does NOT call this
+// automatically, since a SpanMonitor may still be reachable through a
+// context (see StartSpan) after Finish returns; callers that know a
+// SpanMonitor was never stashed in a context, or that have since dropped
+// that context, may call Release themselves to get the pooling benefit.
+func (sm *SpanMonitor) Release() {
+	sm.mu.Lock()
+	for k := range sm.fields {
+		delete(sm.fields, k)
+	}
+	for i := range sm.subeventsArr {
+		sm.subeventsArr[i] = nil
+	}
+	sm.subevents = sm.subeventsArr[:0]
+	sm.SubeventsField = ""
+	sm.span = nil
+	sm.ownsSpan = false
+	sm.otelSpan = nil
+	sm.ownsOtelSpan = false
+	sm.parent = nil
+	sm.suppressed = false
+	sm.flushMode = FlushOnFinish
+	sm.maxBufferedSubevents = 0
+	sm.subeventsDropped = 0
+	sm.recordCount = 0
+	sm.sampled = false
+	sm.sampledHintSet = false
+	sm.mu.Unlock()
+	spanMonitorPool.Put(sm)
+}
+
+// subeventTimestamp picks the timestamp to attach to a span log record for
+// the given subevent, preferring the conventional ECS FieldTimestamp field
+// and falling back to the current time if it's absent or not a time.Time.
+func subeventTimestamp(subevent map[string]interface{}) time.Time {
+	if ts, ok := subevent[FieldTimestamp].(time.Time); ok {
+		return ts
+	}
+	return time.Now()
+}
+
+// subeventLogFields converts a subevent's fields into opentracing log.Field
+// values, choosing the most specific constructor available for the value's
+// type and falling back to log.Object for anything else.
+func subeventLogFields(subevent map[string]interface{}) []log.Field {
+	fields := make([]log.Field, 0, len(subevent))
+	for k, v := range subevent {
+		switch val := v.(type) {
+		case string:
+			fields = append(fields, log.String(k, val))
+		case bool:
+			fields = append(fields, log.Bool(k, val))
+		case int:
+			fields = append(fields, log.Int(k, val))
+		case int32:
+			fields = append(fields, log.Int32(k, val))
+		case int64:
+			fields = append(fields, log.Int64(k, val))
+		case uint32:
+			fields = append(fields, log.Uint32(k, val))
+		case uint64:
+			fields = append(fields, log.Uint64(k, val))
+		case float32:
+			fields = append(fields, log.Float32(k, val))
+		case float64:
+			fields = append(fields, log.Float64(k, val))
+		case error:
+			fields = append(fields, log.Error(val))
+		default:
+			fields = append(fields, log.Object(k, val))
+		}
+	}
+	return fields
+}
+
+// subeventName picks the OpenTelemetry event name for a subevent, preferring
+// the ECS event.action field and falling back to message, then a generic
+// name if neither is present.
+func subeventName(subevent map[string]interface{}) string {
+	if action, ok := subevent[FieldEventAction].(string); ok && action != "" {
+		return action
+	}
+	if msg, ok := subevent[FieldMessage].(string); ok && msg != "" {
+		return msg
+	}
+	return "subevent"
+}
+
+// subeventAttributes converts a subevent's scalar field values into
+// OpenTelemetry attributes, skipping values that don't have a direct
+// attribute.KeyValue representation (e.g. nested maps or slices).
+func subeventAttributes(subevent map[string]interface{}) []attribute.KeyValue {
+	attrs := make([]attribute.KeyValue, 0, len(subevent))
+	for k, v := range subevent {
+		switch val := v.(type) {
+		case string:
+			attrs = append(attrs, attribute.String(k, val))
+		case bool:
+			attrs = append(attrs, attribute.Bool(k, val))
+		case int:
+			attrs = append(attrs, attribute.Int(k, val))
+		case int64:
+			attrs = append(attrs, attribute.Int64(k, val))
+		case float64:
+			attrs = append(attrs, attribute.Float64(k, val))
+		}
+	}
+	return attrs
+}
+
+// applySpanTags promotes well-known ECS fields on a subevent to opentracing
+// tags so exported traces carry the same structured data that a log
+// aggregator would see, even if the log record fields themselves are never
+// inspected by the tracing backend.
+func applySpanTags(span opentracing.Span, subevent map[string]interface{}) {
+	if msg, ok := subevent[FieldErrorMessage].(string); ok && msg != "" {
+		// Just the tag here; the message itself reaches the span as a log
+		// field via subeventLogFields, which already walks every field on
+		// subevent, including this one.
+		ext.Error.Set(span, true)
+	}
+	switch code := subevent[FieldHTTPResponseStatusCode].(type) {
+	case int:
+		ext.HTTPStatusCode.Set(span, uint16(code))
+	case int32:
+		ext.HTTPStatusCode.Set(span, uint16(code))
+	case int64:
+		ext.HTTPStatusCode.Set(span, uint16(code))
+	case uint16:
+		ext.HTTPStatusCode.Set(span, code)
+	case uint32:
+		ext.HTTPStatusCode.Set(span, uint16(code))
+	case uint64:
+		ext.HTTPStatusCode.Set(span, uint16(code))
+	}
 }
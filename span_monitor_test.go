@@ -0,0 +1,180 @@
+package ecsevent
+
+import (
+	"testing"
+
+	"github.com/opentracing/opentracing-go"
+	"github.com/opentracing/opentracing-go/log"
+)
+
+// recordingMonitor is a minimal Monitor used to observe what a SpanMonitor
+// hands upward without needing a RootMonitor.
+type recordingMonitor struct {
+	recorded []map[string]interface{}
+}
+
+func (rm *recordingMonitor) Record(event map[string]interface{}) {
+	rm.recorded = append(rm.recorded, event)
+}
+func (rm *recordingMonitor) Fields() map[string]interface{}      { return nil }
+func (rm *recordingMonitor) UpdateFields(map[string]interface{}) {}
+func (rm *recordingMonitor) Suppress()                           {}
+
+var _ Monitor = &recordingMonitor{}
+
+func TestFlushOnFinishBuffersUntilFinish(t *testing.T) {
+	parent := &recordingMonitor{}
+	sm := NewSpanMonitorFromParent(parent)
+	sm.Record(map[string]interface{}{"n": 1})
+	sm.Record(map[string]interface{}{"n": 2})
+	if len(parent.recorded) != 0 {
+		t.Fatalf("parent saw %d events before Finish, want 0", len(parent.recorded))
+	}
+	sm.Finish()
+	if len(parent.recorded) != 1 {
+		t.Fatalf("parent saw %d events after Finish, want 1 aggregate event", len(parent.recorded))
+	}
+	subevents, _ := parent.recorded[0][sm.SubeventsField].([]map[string]interface{})
+	if len(subevents) != 2 {
+		t.Fatalf("got %d buffered subevents, want 2", len(subevents))
+	}
+}
+
+func TestFlushImmediateEmitsAsRecorded(t *testing.T) {
+	parent := &recordingMonitor{}
+	sm := NewSpanMonitorFromParent(parent, WithFlushMode(FlushImmediate))
+	sm.Record(map[string]interface{}{"n": 1})
+	sm.Record(map[string]interface{}{"n": 2})
+	if len(parent.recorded) != 2 {
+		t.Fatalf("parent saw %d events before Finish, want 2 (flushed immediately)", len(parent.recorded))
+	}
+	sm.Finish()
+	if len(parent.recorded) != 3 {
+		t.Fatalf("parent saw %d events after Finish, want 3 (2 immediate + 1 summary)", len(parent.recorded))
+	}
+	if _, ok := parent.recorded[2][FieldEventSubeventsCount]; !ok {
+		t.Fatal("summary event missing FieldEventSubeventsCount")
+	}
+}
+
+func TestMaxBufferedSubeventsDropsOldest(t *testing.T) {
+	parent := &recordingMonitor{}
+	sm := NewSpanMonitorFromParent(parent, WithMaxBufferedSubevents(2))
+	sm.Record(map[string]interface{}{"n": 1})
+	sm.Record(map[string]interface{}{"n": 2})
+	sm.Record(map[string]interface{}{"n": 3})
+	sm.Finish()
+
+	event := parent.recorded[0]
+	if dropped, _ := event[FieldEventSubeventsDropped].(int); dropped != 1 {
+		t.Fatalf("FieldEventSubeventsDropped = %v, want 1", event[FieldEventSubeventsDropped])
+	}
+	subevents, _ := event[sm.SubeventsField].([]map[string]interface{})
+	if len(subevents) != 2 {
+		t.Fatalf("got %d buffered subevents, want 2 (oldest dropped)", len(subevents))
+	}
+}
+
+func TestFinishOnSampledOutSpanEmitsMinimalEvent(t *testing.T) {
+	parent := &recordingMonitor{}
+	sm := NewSpanMonitorFromParent(parent, WithSampledHint(false))
+	sm.Record(map[string]interface{}{"n": 1})
+	sm.Finish()
+
+	if len(parent.recorded) != 1 {
+		t.Fatalf("parent saw %d events, want 1 minimal event even though the trace was sampled out", len(parent.recorded))
+	}
+	if sampled, _ := parent.recorded[0][FieldTraceSampled].(bool); sampled {
+		t.Fatal("FieldTraceSampled = true on a sampled-out trace, want false")
+	}
+	if _, ok := parent.recorded[0][sm.SubeventsField]; ok {
+		t.Fatal("sampled-out event carries subevents, want the Record call to have been skipped entirely")
+	}
+}
+
+func TestFinishOnSuppressedSpanEmitsNothing(t *testing.T) {
+	parent := &recordingMonitor{}
+	sm := NewSpanMonitorFromParent(parent)
+	sm.Suppress()
+	sm.Record(map[string]interface{}{"n": 1})
+	sm.Finish()
+	if len(parent.recorded) != 0 {
+		t.Fatalf("parent saw %d events on a suppressed span, want 0", len(parent.recorded))
+	}
+}
+
+func TestFinishWithNoRecordsOrFieldsEmitsNothing(t *testing.T) {
+	parent := &recordingMonitor{}
+	sm := NewSpanMonitorFromParent(parent)
+	sm.Finish()
+	if len(parent.recorded) != 0 {
+		t.Fatalf("parent saw %d events for an empty span, want 0", len(parent.recorded))
+	}
+}
+
+func TestReleaseResetsStateForReuse(t *testing.T) {
+	parent := &recordingMonitor{}
+	sm := NewSpanMonitorFromParent(parent, WithMaxBufferedSubevents(1))
+	sm.UpdateFields(map[string]interface{}{"k": "v"})
+	sm.Record(map[string]interface{}{"n": 1})
+	sm.Release()
+
+	if len(sm.Fields()) != 0 {
+		t.Fatalf("fields not cleared by Release: %v", sm.Fields())
+	}
+	if sm.span != nil || sm.ownsSpan || sm.otelSpan != nil || sm.ownsOtelSpan {
+		t.Fatal("span ownership state not cleared by Release")
+	}
+	if sm.parent != nil || sm.maxBufferedSubevents != 0 || sm.recordCount != 0 {
+		t.Fatal("bookkeeping fields not cleared by Release")
+	}
+}
+
+// fakeSpan is a bare-bones opentracing.Span that records whether it was
+// finished and how many times, so tests can assert a shared span is only
+// ever finished once.
+type fakeSpan struct {
+	finishes int
+	logged   [][]interface{}
+	tags     map[string]interface{}
+	ctx      opentracing.SpanContext
+}
+
+func newFakeSpan() *fakeSpan { return &fakeSpan{tags: make(map[string]interface{})} }
+
+func (s *fakeSpan) Finish()                                     { s.finishes++ }
+func (s *fakeSpan) FinishWithOptions(opentracing.FinishOptions) { s.finishes++ }
+func (s *fakeSpan) Context() opentracing.SpanContext            { return s.ctx }
+func (s *fakeSpan) SetOperationName(string) opentracing.Span    { return s }
+func (s *fakeSpan) SetTag(key string, value interface{}) opentracing.Span {
+	s.tags[key] = value
+	return s
+}
+func (s *fakeSpan) LogFields(fields ...log.Field) {}
+func (s *fakeSpan) LogKV(alternatingKeyValues ...interface{}) {
+	s.logged = append(s.logged, alternatingKeyValues)
+}
+func (s *fakeSpan) SetBaggageItem(string, string) opentracing.Span { return s }
+func (s *fakeSpan) BaggageItem(string) string                      { return "" }
+func (s *fakeSpan) Tracer() opentracing.Tracer                     { return nil }
+func (s *fakeSpan) LogEvent(string)                                {}
+func (s *fakeSpan) LogEventWithPayload(string, interface{})        {}
+func (s *fakeSpan) Log(opentracing.LogData)                        {}
+
+func TestChildSpanMonitorDoesNotDoubleFinishInheritedSpan(t *testing.T) {
+	root := &recordingMonitor{}
+	span := newFakeSpan()
+	parentSM := NewSpanMonitorFromParent(root, WithOpenTracingSpan(span))
+	childSM := NewSpanMonitorFromParent(parentSM)
+
+	childSM.Record(map[string]interface{}{"n": 1})
+	childSM.Finish()
+	if span.finishes != 0 {
+		t.Fatalf("inherited span finished %d times by child Finish, want 0", span.finishes)
+	}
+
+	parentSM.Finish()
+	if span.finishes != 1 {
+		t.Fatalf("owned span finished %d times, want exactly 1", span.finishes)
+	}
+}